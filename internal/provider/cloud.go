@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"cloud.google.com/go/cloudsqlconn"
+	csqlmysql "cloud.google.com/go/cloudsqlconn/mysql/mysql"
+	csqlpgx "cloud.google.com/go/cloudsqlconn/postgres/pgxv4"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/go-sql-driver/mysql"
+)
+
+// cloudSQLDriverSeq numbers each Cloud SQL database/sql driver registration,
+// so connect can register a fresh one on every call instead of reusing a
+// fixed name. sql.Register panics if called twice with the same name, and
+// Configure can run connect again on an already-configured provider (e.g. a
+// refresh), so reusing "cloudsql-postgres"/"cloudsql-mysql" as the
+// registered name would panic the whole process on the second connect.
+var cloudSQLDriverSeq int64
+
+// registerCloudSQLDriverName returns a unique database/sql driver name
+// derived from family (the provider's logical driver name, e.g.
+// "cloudsql-postgres"), suitable for a one-time sql.Register call.
+func registerCloudSQLDriverName(family string) string {
+	return fmt.Sprintf("%s-%d", family, atomic.AddInt64(&cloudSQLDriverSeq, 1))
+}
+
+// connectCloud handles the cloudsql-postgres://, cloudsql-mysql://,
+// rds-iam-postgres://, and rds-iam-mysql:// schemes. None of these fit
+// net/url.Parse: a Cloud SQL instance connection name
+// (project:region:instance) has too many colons for net/url's host:port
+// parsing, so connect splits these out before ever calling url.Parse. rest is
+// everything after "<scheme>://".
+func (p *provider) connectCloud(scheme string, rest string, iamAuth bool, credentialsJSON string) error {
+	userinfo, hostPath := "", rest
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		userinfo, hostPath = rest[:at], rest[at+1:]
+	}
+
+	instance, dbNameAndQuery := splitOnce(hostPath, "/")
+	dbName, rawQuery := splitOnce(dbNameAndQuery, "?")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Errorf("unable to parse query parameters: %w", err)
+	}
+
+	user, password := splitOnce(userinfo, ":")
+
+	switch scheme {
+	case "cloudsql-postgres":
+		return p.connectCloudSQLPostgres(instance, dbName, user, password, iamAuth, credentialsJSON, query)
+	case "cloudsql-mysql":
+		return p.connectCloudSQLMySQL(instance, dbName, user, password, iamAuth, credentialsJSON, query)
+	case "rds-iam-postgres":
+		return p.connectRDSIAM("pgx", instance, dbName, user, query)
+	case "rds-iam-mysql":
+		return p.connectRDSIAM("mysql", instance, dbName, user, query)
+	default:
+		return fmt.Errorf("unexpected datasource name scheme: %q", scheme)
+	}
+}
+
+// splitOnce splits s on the first occurrence of sep, returning s unchanged
+// (with an empty second value) if sep isn't present.
+func splitOnce(s string, sep string) (string, string) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// escapeKeywordValue quotes v for use as a value in a libpq keyword/value
+// connection string (e.g. "host=... user=..."), the same way lib/pq's own
+// connector escapes values: wrap in single quotes, and backslash-escape any
+// backslash or single quote already in v. Without this, a password or query
+// value containing a space or quote would corrupt the DSN or inject
+// additional keywords.
+func escapeKeywordValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+func cloudSQLOpts(iamAuth bool, credentialsJSON string) []cloudsqlconn.Option {
+	var opts []cloudsqlconn.Option
+	if iamAuth {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	if credentialsJSON != "" {
+		opts = append(opts, cloudsqlconn.WithCredentialsJSON([]byte(credentialsJSON)))
+	}
+	return opts
+}
+
+// connectCloudSQLPostgres dials a Cloud SQL Postgres instance through the
+// Cloud SQL Go connector's pgx integration, which tunnels the connection over
+// an authenticated, mTLS connection using Application Default Credentials (or
+// credentialsJSON) instead of the ssl_ca_cert/ssl_client_cert/ssl_client_key
+// plumbing connect otherwise uses.
+func (p *provider) connectCloudSQLPostgres(instance string, dbName string, user string, password string, iamAuth bool, credentialsJSON string, query url.Values) error {
+	p.Driver = "cloudsql-postgres"
+
+	registeredDriver := registerCloudSQLDriverName(string(p.Driver))
+	cleanup, err := csqlpgx.RegisterDriver(registeredDriver, cloudSQLOpts(iamAuth, credentialsJSON)...)
+	if err != nil {
+		return fmt.Errorf("unable to register Cloud SQL Postgres driver: %w", err)
+	}
+	p.CloudSQLCleanup = cleanup
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable",
+		escapeKeywordValue(instance), escapeKeywordValue(user), escapeKeywordValue(password), escapeKeywordValue(dbName))
+	for k, vs := range query {
+		for _, v := range vs {
+			dsn += fmt.Sprintf(" %s=%s", k, escapeKeywordValue(v))
+		}
+	}
+
+	p.DB, err = sql.Open(registeredDriver, dsn)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	return nil
+}
+
+// connectCloudSQLMySQL is connectCloudSQLPostgres's MySQL counterpart, using
+// the Cloud SQL Go connector's go-sql-driver/mysql integration.
+func (p *provider) connectCloudSQLMySQL(instance string, dbName string, user string, password string, iamAuth bool, credentialsJSON string, query url.Values) error {
+	p.Driver = "cloudsql-mysql"
+
+	registeredDriver := registerCloudSQLDriverName(string(p.Driver))
+	cleanup, err := csqlmysql.RegisterDriver(registeredDriver, cloudSQLOpts(iamAuth, credentialsJSON)...)
+	if err != nil {
+		return fmt.Errorf("unable to register Cloud SQL MySQL driver: %w", err)
+	}
+	p.CloudSQLCleanup = cleanup
+
+	// Built through mysql.Config rather than a hand-formatted DSN string so
+	// that FormatDSN applies go-sql-driver's own escaping to user/password -
+	// a raw "user:password@..." string can't safely carry a password
+	// containing ':', '@', or '/'.
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = registeredDriver
+	cfg.Addr = instance
+	cfg.DBName = dbName
+	cfg.Params = make(map[string]string, len(query))
+	for k, vs := range query {
+		if len(vs) > 0 {
+			cfg.Params[k] = vs[0]
+		}
+	}
+
+	p.DB, err = sql.Open(registeredDriver, cfg.FormatDSN())
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	return nil
+}
+
+// connectRDSIAM is the AWS analogue of the Cloud SQL connectors above: rather
+// than a static password, it generates a short-lived RDS IAM auth token with
+// the AWS SDK and connects over TLS using that token as the password.
+// endpoint is "host:port", as RDS reports it.
+func (p *provider) connectRDSIAM(driverName string, endpoint string, dbName string, user string, query url.Values) error {
+	host, port := splitOnce(endpoint, ":")
+	if port == "" {
+		return fmt.Errorf("rds-iam datasource names must include a port, e.g. rds-iam-postgres://user@host:5432/dbname")
+	}
+
+	region := query.Get("region")
+	if region == "" {
+		return fmt.Errorf("rds-iam datasource names require a region query parameter")
+	}
+
+	ctx := context.Background()
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("unable to load AWS config: %w", err)
+	}
+
+	token, err := auth.BuildAuthToken(ctx, endpoint, region, user, awsCfg.Credentials)
+	if err != nil {
+		return fmt.Errorf("unable to generate RDS IAM auth token: %w", err)
+	}
+
+	switch driverName {
+	case "pgx":
+		p.Driver = "pgx"
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
+			escapeKeywordValue(host), escapeKeywordValue(port), escapeKeywordValue(user), escapeKeywordValue(token), escapeKeywordValue(dbName))
+		p.DB, err = sql.Open(string(p.Driver), dsn)
+	case "mysql":
+		p.Driver = "mysql"
+		cfg := mysql.NewConfig()
+		cfg.User = user
+		cfg.Passwd = token
+		cfg.Net = "tcp"
+		cfg.Addr = fmt.Sprintf("%s:%s", host, port)
+		cfg.DBName = dbName
+		cfg.TLSConfig = "true"
+		cfg.AllowCleartextPasswords = true
+		p.DB, err = sql.Open(string(p.Driver), cfg.FormatDSN())
+	default:
+		return fmt.Errorf("unexpected rds-iam driver: %q", driverName)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	return nil
+}
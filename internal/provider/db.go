@@ -5,17 +5,22 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
 
-	_ "github.com/denisenkom/go-mssqldb"
+	mssql "github.com/denisenkom/go-mssqldb"
 	"github.com/go-sql-driver/mysql"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tftypes"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 type dbQueryer interface {
@@ -26,9 +31,19 @@ type dbExecer interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
-func (p *provider) connect(dsn string, caCert string, caClientCert string, caClientKey string) error {
+func (p *provider) connect(dsn string, caCert string, caClientCert string, caClientKey string, sslMode string, serverName string, iamAuth bool, credentialsJSON string) error {
 	var err error
 
+	// Cloud SQL and RDS IAM DSNs embed an instance connection name
+	// (project:region:instance) or endpoint that net/url can't parse as a
+	// host, so they're peeled off before url.Parse ever sees them.
+	if dsnScheme, err := schemeFromURL(dsn); err == nil {
+		switch dsnScheme {
+		case "cloudsql-postgres", "cloudsql-mysql", "rds-iam-postgres", "rds-iam-mysql":
+			return p.connectCloud(dsnScheme, strings.TrimPrefix(dsn, dsnScheme+"://"), iamAuth, credentialsJSON)
+		}
+	}
+
 	parsed_url, err := url.Parse(dsn)
 
 	if err != nil {
@@ -37,10 +52,66 @@ func (p *provider) connect(dsn string, caCert string, caClientCert string, caCli
 
 	var scheme = parsed_url.Scheme
 
+	// connDSN ends up holding whatever string we eventually hand to sql.Open,
+	// since drivers other than sqlite3 still build their connection string
+	// from parsed_url below.
+	var connDSN string
+
+	// set for drivers that need a custom driver.Connector instead of a plain
+	// DSN string in order to attach a *tls.Config (sqlserver).
+	var connector driver.Connector
+
 	switch scheme {
 	case "postgres", "postgresql":
 		// TODO: use consts for these driver names?
 		p.Driver = "pgx"
+
+		if sslMode != "disable" {
+			values := parsed_url.Query()
+			values.Set("sslmode", sslMode)
+
+			if caCert != "" || caClientCert != "" {
+				certDir, err := os.MkdirTemp("", "terraform-provider-sql-tls")
+				if err != nil {
+					return fmt.Errorf("unable to create temp dir for TLS material: %w", err)
+				}
+
+				if caCert != "" {
+					caCertPath := filepath.Join(certDir, "ca.pem")
+					if err := os.WriteFile(caCertPath, []byte(caCert), 0600); err != nil {
+						return fmt.Errorf("unable to write ssl_ca_cert: %w", err)
+					}
+					values.Set("sslrootcert", caCertPath)
+				}
+
+				if caClientCert != "" {
+					certPath := filepath.Join(certDir, "client.pem")
+					if err := os.WriteFile(certPath, []byte(caClientCert), 0600); err != nil {
+						return fmt.Errorf("unable to write ssl_client_cert: %w", err)
+					}
+					values.Set("sslcert", certPath)
+
+					keyPath := filepath.Join(certDir, "client-key.pem")
+					if err := os.WriteFile(keyPath, []byte(caClientKey), 0600); err != nil {
+						return fmt.Errorf("unable to write ssl_client_key: %w", err)
+					}
+					values.Set("sslkey", keyPath)
+				}
+			}
+
+			parsed_url.RawQuery = values.Encode()
+		}
+	case "sqlite", "file":
+		p.Driver = "sqlite3"
+		switch scheme {
+		case "sqlite":
+			// sqlite://path/to/file.db or sqlite://:memory:
+			connDSN = strings.TrimPrefix(dsn, "sqlite://")
+		case "file":
+			// go-sqlite3 understands file: URIs (including file::memory:?cache=shared)
+			// natively, so pass the DSN through unmodified.
+			connDSN = dsn
+		}
 	case "mysql":
 		p.Driver = "mysql"
 		dsn = strings.TrimPrefix(dsn, "mysql://")
@@ -49,43 +120,104 @@ func (p *provider) connect(dsn string, caCert string, caClientCert string, caCli
 
 		// TODO: also set parseTime=true https://github.com/go-sql-driver/mysql#parsetime
 
-		if caCert != "" {
-			pool := x509.NewCertPool()
-			if ok := pool.AppendCertsFromPEM([]byte(caCert)); !ok {
-				return err
-			}
-			cert, err := tls.X509KeyPair([]byte(caClientCert), []byte(caClientKey))
+		if sslMode != "disable" {
+			tlsConfig, err := tlsConfigForSSLMode(sslMode, caCert, caClientCert, caClientKey, serverName)
 			if err != nil {
 				return err
 			}
-			mysql.RegisterTLSConfig("cloudsql", &tls.Config{
-				RootCAs:               pool,
-				Certificates:          []tls.Certificate{cert},
-				InsecureSkipVerify:    true,
-				VerifyPeerCertificate: verifyPeerCertFunc(pool),
-			})
+			mysql.RegisterTLSConfig("custom", tlsConfig)
 			values := parsed_url.Query()
-			values.Add("tls", "cloudsql")
+			values.Add("tls", "custom")
 			parsed_url.RawQuery = values.Encode()
 		}
 
 	case "sqlserver":
 		p.Driver = "sqlserver"
+
+		if sslMode != "disable" {
+			tlsConfig, err := tlsConfigForSSLMode(sslMode, caCert, caClientCert, caClientKey, serverName)
+			if err != nil {
+				return err
+			}
+
+			c, err := mssql.NewConnector(parsed_url.String())
+			if err != nil {
+				return fmt.Errorf("unable to create sqlserver connector: %w", err)
+			}
+			c.TLSConfig = tlsConfig
+			connector = c
+		}
 	default:
 		return fmt.Errorf("unexpected datasource name scheme: %q", scheme)
 	}
 
-	p.DB, err = sql.Open(string(p.Driver), parsed_url.String())
-	if err != nil {
-		return fmt.Errorf("unable to open database: %w, string %s", err, parsed_url.String())
+	if connDSN == "" {
+		connDSN = parsed_url.String()
+	}
+
+	if connector != nil {
+		p.DB = sql.OpenDB(connector)
+	} else {
+		p.DB, err = sql.Open(string(p.Driver), connDSN)
+		if err != nil {
+			return fmt.Errorf("unable to open database: %w, string %s", err, connDSN)
+		}
 	}
 
 	// force this to zero, but let callers override config
 	p.DB.SetMaxIdleConns(0)
 
+	if p.Driver == "sqlite3" {
+		// SQLite does not support concurrent writers, so constrain the pool to a
+		// single connection, the same pattern the dex project's SQLite backend
+		// uses. Configure may still raise this if the caller set max_open_conns
+		// explicitly.
+		p.DB.SetMaxOpenConns(1)
+	}
+
 	return nil
 }
 
+// tlsConfigForSSLMode builds a *tls.Config honoring the same disable/require/
+// verify-ca/verify-full modes Postgres uses for its sslmode parameter, so
+// MySQL and SQL Server connections can opt into the same verification levels.
+func tlsConfigForSSLMode(sslMode string, caCert string, clientCert string, clientKey string, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if ok := pool.AppendCertsFromPEM([]byte(caCert)); !ok {
+			return nil, fmt.Errorf("unable to append ssl_ca_cert to certificate pool")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if clientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch sslMode {
+	case "require":
+		cfg.InsecureSkipVerify = true
+	case "verify-ca":
+		cfg.InsecureSkipVerify = true
+		if cfg.RootCAs != nil {
+			cfg.VerifyPeerCertificate = verifyPeerCertFunc(cfg.RootCAs)
+		}
+	case "verify-full":
+		if serverName == "" {
+			return nil, fmt.Errorf("ssl_mode is verify-full but no server_name could be determined; set server_name explicitly")
+		}
+		cfg.ServerName = serverName
+	}
+
+	return cfg, nil
+}
+
 func schemeFromURL(url string) (string, error) {
 	if url == "" {
 		return "", fmt.Errorf("a datasource name is required")
@@ -109,9 +241,10 @@ func (p *provider) ValuesForRow(rows *sql.Rows) (map[string]tftypes.Value, map[s
 
 	pointers := make([]interface{}, len(colTypes))
 	row := map[string]struct {
-		index int
-		ty    tftypes.Type
-		val   interface{}
+		index   int
+		ty      tftypes.Type
+		val     interface{}
+		convert valueConverter
 	}{}
 
 	for i, colType := range colTypes {
@@ -120,7 +253,7 @@ func (p *provider) ValuesForRow(rows *sql.Rows) (map[string]tftypes.Value, map[s
 			name = fmt.Sprintf("column%d", i)
 		}
 
-		ty, rty, err := p.typeAndValueForColType(colType)
+		ty, rty, convert, err := p.typeAndValueForColType(colType)
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to determine type for %q: %w", name, err)
 		}
@@ -129,10 +262,11 @@ func (p *provider) ValuesForRow(rows *sql.Rows) (map[string]tftypes.Value, map[s
 		pointers[i] = val.Interface()
 
 		row[name] = struct {
-			index int
-			ty    tftypes.Type
-			val   interface{}
-		}{i, ty, val.Interface()}
+			index   int
+			ty      tftypes.Type
+			val     interface{}
+			convert valueConverter
+		}{i, ty, val.Interface(), convert}
 	}
 
 	err = rows.Scan(pointers...)
@@ -143,6 +277,16 @@ func (p *provider) ValuesForRow(rows *sql.Rows) (map[string]tftypes.Value, map[s
 	rowValues := map[string]tftypes.Value{}
 	rowTypes := map[string]tftypes.Type{}
 	for k, v := range row {
+		if v.convert != nil {
+			tv, err := v.convert(v.val)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to convert value for %q: %w", k, err)
+			}
+			rowValues[k] = tv
+			rowTypes[k] = v.ty
+			continue
+		}
+
 		val := v.val
 
 		// unwrap sql types
@@ -196,7 +340,19 @@ func (p *provider) ValuesForRow(rows *sql.Rows) (map[string]tftypes.Value, map[s
 	return rowValues, rowTypes, nil
 }
 
-func (p *provider) typeAndValueForColType(colType *sql.ColumnType) (tftypes.Type, reflect.Type, error) {
+// valueConverter turns a scanned column value into a tftypes.Value of the
+// type returned alongside it from typeAndValueForColType, for column types
+// (arrays, JSON) that don't fit the generic sql.Null* unwrapping in
+// ValuesForRow.
+type valueConverter func(scanned interface{}) (tftypes.Value, error)
+
+// typeAndValueForColType dispatches on p.Driver, the provider's logical driver
+// name (for Cloud SQL this is the family name, not the numbered name
+// registered with database/sql), so the Cloud SQL connectors'
+// "cloudsql-mysql"/"cloudsql-postgres" names are handled alongside their
+// direct-connection "mysql"/"pgx" counterparts: same wire protocol, same
+// column type mapping.
+func (p *provider) typeAndValueForColType(colType *sql.ColumnType) (tftypes.Type, reflect.Type, valueConverter, error) {
 	scanType := colType.ScanType()
 	kind := scanType.Kind()
 
@@ -204,28 +360,58 @@ func (p *provider) typeAndValueForColType(colType *sql.ColumnType) (tftypes.Type
 	case "sqlserver":
 		switch dbName := colType.DatabaseTypeName(); dbName {
 		case "UNIQUEIDENTIFIER":
-			return tftypes.String, reflect.TypeOf((*sqlServerUniqueIdentifier)(nil)).Elem(), nil
+			// SQL Server has no native array type, so there's no equivalent of the
+			// Postgres array handling below to add here.
+			return tftypes.String, reflect.TypeOf((*sqlServerUniqueIdentifier)(nil)).Elem(), nil, nil
 		case "DECIMAL", "MONEY", "SMALLMONEY":
 			// TODO: add diags about converting to numeric?
-			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
 		}
-	case "mysql":
+	case "mysql", "cloudsql-mysql":
 		switch dbName := colType.DatabaseTypeName(); dbName {
 		case "YEAR":
-			return tftypes.Number, reflect.TypeOf((*sql.NullInt32)(nil)).Elem(), nil
-		case "VARCHAR", "DECIMAL", "TIME", "JSON":
-			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil
+			return tftypes.Number, reflect.TypeOf((*sql.NullInt32)(nil)).Elem(), nil, nil
+		case "JSON":
+			return tftypes.DynamicPseudoType, reflect.TypeOf((*sql.RawBytes)(nil)).Elem(), jsonValueConverter, nil
+		case "VARCHAR", "DECIMAL", "TIME":
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
 		case "DATE", "DATETIME":
-			return tftypes.String, reflect.TypeOf((*sql.NullTime)(nil)).Elem(), nil
+			return tftypes.String, reflect.TypeOf((*sql.NullTime)(nil)).Elem(), nil, nil
 		}
-	case "pgx":
-		switch dbName := colType.DatabaseTypeName(); dbName {
+	case "pgx", "cloudsql-postgres":
+		dbName := colType.DatabaseTypeName()
+
+		if strings.HasPrefix(dbName, "_") {
+			return arrayTypeAndConverter(dbName)
+		}
+
+		switch dbName {
 		// 790 is the oid of money
 		case "MONEY", "790":
 			// TODO: add diags about converting to numeric?
-			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
 		case "TIMESTAMPTZ", "TIMESTAMP", "DATE":
-			return tftypes.String, reflect.TypeOf((*sql.NullTime)(nil)).Elem(), nil
+			return tftypes.String, reflect.TypeOf((*sql.NullTime)(nil)).Elem(), nil, nil
+		case "JSON", "JSONB":
+			return tftypes.DynamicPseudoType, reflect.TypeOf((*sql.RawBytes)(nil)).Elem(), jsonValueConverter, nil
+		case "UUID", "INET", "CIDR":
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
+		}
+	case "sqlite3":
+		// SQLite uses type affinity rather than rigid column types, so map the
+		// declared type name onto the five affinity classes rather than relying
+		// on ScanType, which go-sqlite3 reports as interface{} for most columns.
+		switch dbName := strings.ToUpper(colType.DatabaseTypeName()); {
+		case dbName == "INTEGER" || dbName == "INT" || dbName == "BOOLEAN":
+			return tftypes.Number, reflect.TypeOf((*sql.NullInt64)(nil)).Elem(), nil, nil
+		case dbName == "REAL" || dbName == "FLOAT" || dbName == "DOUBLE" ||
+			strings.Contains(dbName, "NUMERIC") || strings.Contains(dbName, "DECIMAL"):
+			return tftypes.Number, reflect.TypeOf((*sql.NullFloat64)(nil)).Elem(), nil, nil
+		case dbName == "BLOB" || dbName == "":
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
+		default:
+			// TEXT, VARCHAR(n), CHAR(n), CLOB, DATE, DATETIME, etc. all have text affinity.
+			return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
 		}
 	}
 
@@ -233,32 +419,102 @@ func (p *provider) typeAndValueForColType(colType *sql.ColumnType) (tftypes.Type
 	case reflect.TypeOf((*sql.NullInt64)(nil)).Elem(),
 		reflect.TypeOf((*sql.NullInt32)(nil)).Elem(),
 		reflect.TypeOf((*sql.NullFloat64)(nil)).Elem():
-		return tftypes.Number, scanType, nil
+		return tftypes.Number, scanType, nil, nil
 	case reflect.TypeOf((*sql.NullString)(nil)).Elem():
-		return tftypes.String, scanType, nil
+		return tftypes.String, scanType, nil, nil
 	case reflect.TypeOf((*sql.NullBool)(nil)).Elem():
-		return tftypes.Bool, scanType, nil
+		return tftypes.Bool, scanType, nil, nil
 	case reflect.TypeOf((*sql.NullTime)(nil)).Elem():
-		return tftypes.String, scanType, nil
+		return tftypes.String, scanType, nil, nil
 	}
 
 	// Force nullable typing for primitives
 	switch kind {
 	case reflect.String:
-		return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil
+		return tftypes.String, reflect.TypeOf((*sql.NullString)(nil)).Elem(), nil, nil
 	case reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Int,
 		reflect.Uint32, reflect.Uint16, reflect.Uint8, reflect.Uint:
-		return tftypes.Number, reflect.TypeOf((*sql.NullInt64)(nil)).Elem(), nil
+		return tftypes.Number, reflect.TypeOf((*sql.NullInt64)(nil)).Elem(), nil, nil
 	case reflect.Uint64:
 		// TODO: uint64 may be a problem in nullint64 if too large?
-		return tftypes.Number, reflect.TypeOf((*sql.NullInt64)(nil)).Elem(), nil
+		return tftypes.Number, reflect.TypeOf((*sql.NullInt64)(nil)).Elem(), nil, nil
 	case reflect.Float32, reflect.Float64:
-		return tftypes.Number, reflect.TypeOf((*sql.NullFloat64)(nil)).Elem(), nil
+		return tftypes.Number, reflect.TypeOf((*sql.NullFloat64)(nil)).Elem(), nil, nil
 	case reflect.Bool:
-		return tftypes.Bool, reflect.TypeOf((*sql.NullBool)(nil)).Elem(), nil
+		return tftypes.Bool, reflect.TypeOf((*sql.NullBool)(nil)).Elem(), nil, nil
+	}
+
+	return nil, nil, nil, fmt.Errorf("unexpected type for %q: %q (%s %s)", colType.Name(), colType.DatabaseTypeName(), kind, scanType)
+}
+
+// arrayTypeAndConverter maps a Postgres array type name, upper-cased as
+// DatabaseTypeName reports it (e.g. "_INT4", "_TEXT"), onto a tftypes.List of the element type, a lib/pq array scanner
+// for the element's Go type, and the converter that turns the scanned array
+// into a tftypes.Value.
+func arrayTypeAndConverter(dbName string) (tftypes.Type, reflect.Type, valueConverter, error) {
+	switch strings.TrimPrefix(dbName, "_") {
+	case "INT2", "INT4", "INT8":
+		return tftypes.List{ElementType: tftypes.Number}, reflect.TypeOf(pq.Int64Array{}), int64ArrayValueConverter, nil
+	case "FLOAT4", "FLOAT8", "NUMERIC":
+		return tftypes.List{ElementType: tftypes.Number}, reflect.TypeOf(pq.Float64Array{}), float64ArrayValueConverter, nil
+	case "BOOL":
+		return tftypes.List{ElementType: tftypes.Bool}, reflect.TypeOf(pq.BoolArray{}), boolArrayValueConverter, nil
+	case "TEXT", "VARCHAR", "BPCHAR", "UUID":
+		return tftypes.List{ElementType: tftypes.String}, reflect.TypeOf(pq.StringArray{}), stringArrayValueConverter, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported array element type %q", dbName)
+	}
+}
+
+func stringArrayValueConverter(scanned interface{}) (tftypes.Value, error) {
+	arr := scanned.(*pq.StringArray)
+	ty := tftypes.List{ElementType: tftypes.String}
+	vals := make([]tftypes.Value, len(*arr))
+	for i, s := range *arr {
+		vals[i] = tftypes.NewValue(tftypes.String, s)
+	}
+	return tftypes.NewValue(ty, vals), nil
+}
+
+func int64ArrayValueConverter(scanned interface{}) (tftypes.Value, error) {
+	arr := scanned.(*pq.Int64Array)
+	ty := tftypes.List{ElementType: tftypes.Number}
+	vals := make([]tftypes.Value, len(*arr))
+	for i, n := range *arr {
+		vals[i] = tftypes.NewValue(tftypes.Number, n)
 	}
+	return tftypes.NewValue(ty, vals), nil
+}
+
+func float64ArrayValueConverter(scanned interface{}) (tftypes.Value, error) {
+	arr := scanned.(*pq.Float64Array)
+	ty := tftypes.List{ElementType: tftypes.Number}
+	vals := make([]tftypes.Value, len(*arr))
+	for i, n := range *arr {
+		vals[i] = tftypes.NewValue(tftypes.Number, n)
+	}
+	return tftypes.NewValue(ty, vals), nil
+}
 
-	return nil, nil, fmt.Errorf("unexpected type for %q: %q (%s %s)", colType.Name(), colType.DatabaseTypeName(), kind, scanType)
+func boolArrayValueConverter(scanned interface{}) (tftypes.Value, error) {
+	arr := scanned.(*pq.BoolArray)
+	ty := tftypes.List{ElementType: tftypes.Bool}
+	vals := make([]tftypes.Value, len(*arr))
+	for i, b := range *arr {
+		vals[i] = tftypes.NewValue(tftypes.Bool, b)
+	}
+	return tftypes.NewValue(ty, vals), nil
+}
+
+// jsonValueConverter parses a JSON/JSONB column's raw bytes into a
+// tftypes.Value of DynamicPseudoType, rather than returning the raw text, so
+// callers can traverse the decoded structure directly.
+func jsonValueConverter(scanned interface{}) (tftypes.Value, error) {
+	raw := scanned.(*sql.RawBytes)
+	if len(*raw) == 0 {
+		return tftypes.NewValue(tftypes.DynamicPseudoType, nil), nil
+	}
+	return tftypes.ValueFromJSON(*raw, tftypes.DynamicPseudoType)
 }
 
 // verifyPeerCertFunc returns a function that verifies the peer certificate is
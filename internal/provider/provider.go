@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"math/big"
+	neturl "net/url"
 	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tftypes"
@@ -26,6 +28,7 @@ func New(version string) func() tfprotov5.ProviderServer {
 		// resources
 		s.MustRegisterResource("sql_migrate", newResourceMigrate)
 		s.MustRegisterResource("sql_migrate_directory", newResourceMigrateDirectory)
+		s.MustRegisterResource("sql_transaction", newResourceTransaction)
 
 		return s
 	}
@@ -38,6 +41,18 @@ type provider struct {
 	DB *sql.DB `argmapper:",typeOnly"`
 
 	Driver driverName
+
+	MaxRetries       int
+	RetryMaxInterval time.Duration
+
+	// CloudSQLCleanup releases the Cloud SQL Go connector's dialer, if
+	// connect used one. Non-nil only for cloudsql-postgres:// and
+	// cloudsql-mysql:// urls.
+	CloudSQLCleanup func() error
+}
+
+func (p *provider) retryPolicy() retryPolicy {
+	return retryPolicy{MaxRetries: p.MaxRetries, MaxInterval: p.RetryMaxInterval}
 }
 
 var _ server.Provider = (*provider)(nil)
@@ -73,25 +88,76 @@ func (p *provider) Schema(context.Context) *tfprotov5.Schema {
 					Type:            tftypes.Number,
 				},
 				{
-                    Name: "ssl_ca_cert",
-                    Optional: true,
-                    Description: "Accepts a PEM formatted SSL CA certificate to be used for the connection to the database",
-                    DescriptionKind: tfprotov5.StringKindMarkdown,
-                    Type: tftypes.String,
+					Name:            "ssl_ca_cert",
+					Optional:        true,
+					Description:     "Accepts a PEM formatted SSL CA certificate to be used for the connection to the database",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:            "ssl_client_cert",
+					Optional:        true,
+					Description:     "Accepts a PEM formatted SSL client certificate to be used for the connection to the database",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:            "ssl_client_key",
+					Optional:        true,
+					Description:     "Accepts a SSL client private key to be used for the connection to the database",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:     "ssl_mode",
+					Optional: true,
+					Description: "Controls how strictly the connection's TLS certificate is verified. One of `disable`, `require`, " +
+						"`verify-ca`, or `verify-full`. Supported for the `postgres`, `mysql`, and `sqlserver` drivers. Defaults to " +
+						"`verify-ca` when `ssl_ca_cert` is set, otherwise `disable`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
 				},
 				{
-                    Name: "ssl_client_cert",
-                    Optional: true,
-                    Description: "Accepts a PEM formatted SSL client certificate to be used for the connection to the database",
-                    DescriptionKind: tfprotov5.StringKindMarkdown,
-                    Type: tftypes.String,
+					Name:     "server_name",
+					Optional: true,
+					Description: "The server name used to verify the connection's TLS certificate when `ssl_mode` is `verify-full`. " +
+						"Defaults to the host in `url`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
 				},
 				{
-                    Name: "ssl_client_key",
-                    Optional: true,
-                    Description: "Accepts a SSL client private key to be used for the connection to the database",
-                    DescriptionKind: tfprotov5.StringKindMarkdown,
-                    Type: tftypes.String,
+					Name:     "max_retries",
+					Optional: true,
+					Description: "Sets the number of times a `sql_transaction` resource's statements or read query are retried after " +
+						"a transient error (a serialization failure or deadlock) before giving up. `sql_query`, `sql_migrate`, and " +
+						"`sql_migrate_directory` do not retry. Default is `5`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.Number,
+				},
+				{
+					Name:     "retry_max_interval",
+					Optional: true,
+					Description: "Sets the maximum backoff interval between retries of a transient error, as a Go duration string " +
+						"(e.g. `5s`). Default is `5s`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:     "iam_auth",
+					Optional: true,
+					Description: "When `true`, authenticate using IAM database authentication instead of a static password. Only " +
+						"applies to `cloudsql-postgres://` and `cloudsql-mysql://` urls.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.Bool,
+				},
+				{
+					Name:     "credentials_json",
+					Optional: true,
+					Description: "Service account or workload identity credentials, as JSON, used to authenticate to Cloud SQL. " +
+						"Defaults to Application Default Credentials when unset. Only applies to `cloudsql-postgres://` and " +
+						"`cloudsql-mysql://` urls.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
 				},
 			},
 		},
@@ -107,16 +173,22 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		// if reconfiguring, close existing connection
 		_ = p.DB.Close()
 	}
+	if p.CloudSQLCleanup != nil {
+		_ = p.CloudSQLCleanup()
+		p.CloudSQLCleanup = nil
+	}
 
 	var err error
 
 	var (
-		url          string
-		maxOpenConns *big.Float
-		maxIdleConns *big.Float
-		ssl_ca_cert string
-		ssl_client_cert string
-		ssl_client_key string
+		url           string
+		maxOpenConns  *big.Float
+		maxIdleConns  *big.Float
+		sslCACert     string
+		sslClientCert string
+		sslClientKey  string
+		sslMode       string
+		serverName    string
 	)
 	if v := config["url"]; v.IsNull() {
 		url = os.Getenv("SQL_URL")
@@ -162,7 +234,7 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		}
 	}
 
-    if v := config["ssl_ca_cert"]; v.IsNull() {
+	if v := config["ssl_ca_cert"]; v.IsNull() {
 		sslCACert = ""
 	} else {
 		err = config["ssl_ca_cert"].As(&sslCACert)
@@ -172,7 +244,7 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		}
 	}
 
-    if v := config["ssl_client_cert"]; v.IsNull() {
+	if v := config["ssl_client_cert"]; v.IsNull() {
 		sslClientCert = ""
 	} else {
 		err = config["ssl_client_cert"].As(&sslClientCert)
@@ -182,7 +254,7 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		}
 	}
 
-    if v := config["ssl_client_key"]; v.IsNull() {
+	if v := config["ssl_client_key"]; v.IsNull() {
 		sslClientKey = ""
 	} else {
 		err = config["ssl_client_key"].As(&sslClientKey)
@@ -192,7 +264,107 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		}
 	}
 
-	err = p.connect(url, sslCACert, sslClientCert, sslClientKey)
+	if v := config["ssl_mode"]; v.IsNull() {
+		if sslCACert != "" {
+			sslMode = "verify-ca"
+		} else {
+			sslMode = "disable"
+		}
+	} else {
+		err = config["ssl_mode"].As(&sslMode)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read ssl_mode: %w", err)
+		}
+	}
+
+	switch sslMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return []*tfprotov5.Diagnostic{
+			{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Attribute: &tftypes.AttributePath{Steps: []tftypes.AttributePathStep{
+					tftypes.AttributeName("ssl_mode"),
+				}},
+				Summary: fmt.Sprintf("ssl_mode must be one of disable, require, verify-ca, or verify-full, got %q.", sslMode),
+			},
+		}, nil
+	}
+
+	if v := config["server_name"]; v.IsNull() {
+		// server_name defaults to the host portion of url, so verify-full
+		// verifies against the host the provider is actually dialing.
+		if parsed, err := neturl.Parse(url); err == nil {
+			serverName = parsed.Hostname()
+		}
+	} else {
+		err = config["server_name"].As(&serverName)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read server_name: %w", err)
+		}
+	}
+
+	var maxRetries *big.Float
+	if v := config["max_retries"]; v.IsNull() {
+		maxRetries = big.NewFloat(float64(5))
+	} else {
+		maxRetries = &big.Float{}
+		err = v.As(&maxRetries)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read max_retries: %w", err)
+		}
+	}
+
+	var retryMaxIntervalStr string
+	if v := config["retry_max_interval"]; v.IsNull() {
+		retryMaxIntervalStr = "5s"
+	} else {
+		err = v.As(&retryMaxIntervalStr)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read retry_max_interval: %w", err)
+		}
+	}
+
+	retryMaxInterval, err := time.ParseDuration(retryMaxIntervalStr)
+	if err != nil {
+		return []*tfprotov5.Diagnostic{
+			{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Attribute: &tftypes.AttributePath{Steps: []tftypes.AttributePathStep{
+					tftypes.AttributeName("retry_max_interval"),
+				}},
+				Summary: fmt.Sprintf("retry_max_interval must be a valid Go duration, got %q: %s", retryMaxIntervalStr, err),
+			},
+		}, nil
+	}
+
+	var iamAuth bool
+	if v := config["iam_auth"]; v.IsNull() {
+		iamAuth = false
+	} else {
+		err = v.As(&iamAuth)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read iam_auth: %w", err)
+		}
+	}
+
+	var credentialsJSON string
+	if v := config["credentials_json"]; v.IsNull() {
+		credentialsJSON = ""
+	} else {
+		err = config["credentials_json"].As(&credentialsJSON)
+		if err != nil {
+			// TODO: diag with path
+			return nil, fmt.Errorf("ConfigureProvider - unable to read credentials_json: %w", err)
+		}
+	}
+
+	err = p.connect(url, sslCACert, sslClientCert, sslClientKey, sslMode, serverName, iamAuth, credentialsJSON)
 	if err != nil {
 		return nil, fmt.Errorf("ConfigureProvider - unable to open database: %w", err)
 	}
@@ -207,9 +379,21 @@ func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Valu
 		return nil, fmt.Errorf("ConfigureProvider - results for max_open_conns is not exact")
 	}
 
-	p.DB.SetMaxOpenConns(int(maxOpen))
+	if v := config["max_open_conns"]; v.IsNull() && p.Driver == "sqlite3" {
+		// leave the single-connection pool connect established for SQLite alone
+		// unless the caller explicitly overrode max_open_conns.
+	} else {
+		p.DB.SetMaxOpenConns(int(maxOpen))
+	}
 	p.DB.SetMaxIdleConns(int(maxIdle))
 
+	maxRetriesInt, acc := maxRetries.Int64()
+	if acc != big.Exact {
+		return nil, fmt.Errorf("ConfigureProvider - results for max_retries is not exact")
+	}
+	p.MaxRetries = int(maxRetriesInt)
+	p.RetryMaxInterval = retryMaxInterval
+
 	err = p.DB.PingContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("ConfigureProvider - unable to ping database: %w", err)
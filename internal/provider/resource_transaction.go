@@ -0,0 +1,341 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tftypes"
+
+	"github.com/paultyng/terraform-provider-sql/internal/server"
+)
+
+// txIsolation maps the resource's isolation attribute onto the standard
+// library's sql.IsolationLevel.
+type txIsolation string
+
+const (
+	txIsolationReadCommitted  txIsolation = "read_committed"
+	txIsolationRepeatableRead txIsolation = "repeatable_read"
+	txIsolationSerializable   txIsolation = "serializable"
+)
+
+func (i txIsolation) sqlIsolationLevel() (sql.IsolationLevel, error) {
+	switch i {
+	case "", txIsolationReadCommitted:
+		return sql.LevelReadCommitted, nil
+	case txIsolationRepeatableRead:
+		return sql.LevelRepeatableRead, nil
+	case txIsolationSerializable:
+		return sql.LevelSerializable, nil
+	default:
+		return 0, fmt.Errorf("unknown isolation level %q", i)
+	}
+}
+
+// txStatement is one entry in a sql_transaction resource's statements list:
+// the SQL to run on apply, and the SQL (if any) to undo it on destroy.
+type txStatement struct {
+	Apply   string
+	Destroy string
+}
+
+// statementsElementType describes one entry of the statements list attribute.
+var statementsElementType = tftypes.Object{
+	AttributeTypes: map[string]tftypes.Type{
+		"apply":   tftypes.String,
+		"destroy": tftypes.String,
+	},
+}
+
+func newResourceTransaction() server.Resource {
+	return &resourceTransaction{}
+}
+
+// resourceTransaction backs sql_transaction: an ordered list of statements
+// applied (or, on destroy, unwound) inside a single database transaction, so
+// they succeed or fail as a unit. It fills the gap between sql_migrate's
+// up/down pairs and one-off sql_query reads.
+type resourceTransaction struct {
+	DB *sql.DB `argmapper:",typeOnly"`
+
+	Policy retryPolicy `argmapper:",typeOnly"`
+}
+
+var _ server.Resource = (*resourceTransaction)(nil)
+
+func (r *resourceTransaction) Schema(context.Context) *tfprotov5.Schema {
+	return &tfprotov5.Schema{
+		Block: &tfprotov5.SchemaBlock{
+			Attributes: []*tfprotov5.SchemaAttribute{
+				{
+					Name:     "statements",
+					Required: true,
+					Description: "An ordered list of statements to run as a single transaction. Each entry has an `apply` SQL " +
+						"statement to run on create/update, and an optional `destroy` statement to run, in reverse order, on destroy. " +
+						"An update destroys the changed part of the old list and applies the changed part of the new list as two " +
+						"separate transactions.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.List{ElementType: statementsElementType},
+				},
+				{
+					Name:     "isolation",
+					Optional: true,
+					Description: "The transaction isolation level to use: `read_committed`, `repeatable_read`, or `serializable`. " +
+						"Default is `read_committed`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:            "read",
+					Optional:        true,
+					Description:     "A query run on read to detect drift in the statements this resource applied.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+				{
+					Name:            "id",
+					Computed:        true,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Type:            tftypes.String,
+				},
+			},
+		},
+	}
+}
+
+func (r *resourceTransaction) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	return nil, nil
+}
+
+// applyStatements runs each statement in order inside a single transaction at
+// the given isolation level, committing only if every statement succeeds and
+// rolling back the whole batch otherwise. destroy selects whether Apply or
+// Destroy is run for each statement, in forward or reverse order
+// respectively. On failure the error identifies the statement index that
+// failed, so the caller can attach diagnostics to the right list element;
+// first is the index in the statements list attribute of statements[0].
+//
+// A transient error (serialization failure, deadlock) aborts the whole
+// transaction, not just the statement that hit it - retrying a single
+// ExecContext against the same tx would just re-surface "transaction is
+// aborted" rather than retry anything. So the retry policy wraps the entire
+// begin/exec/commit attempt, rolling back and starting a fresh transaction
+// each time.
+func (r *resourceTransaction) applyStatements(ctx context.Context, isolation txIsolation, statements []txStatement, destroy bool, first int) error {
+	level, err := isolation.sqlIsolationLevel()
+	if err != nil {
+		return err
+	}
+
+	indexes := make([]int, len(statements))
+	for i := range statements {
+		if destroy {
+			indexes[i] = len(statements) - 1 - i
+		} else {
+			indexes[i] = i
+		}
+	}
+
+	return r.Policy.Do(ctx, func() error {
+		tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: level})
+		if err != nil {
+			return fmt.Errorf("unable to begin transaction: %w", err)
+		}
+
+		for _, i := range indexes {
+			query := statements[i].Apply
+			if destroy {
+				query = statements[i].Destroy
+			}
+			if query == "" {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("statement %d failed: %w", first+i, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("unable to commit transaction: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// decodeStatements reads the statements list attribute into the []txStatement
+// applyStatements expects.
+func decodeStatements(v tftypes.Value) ([]txStatement, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	var elems []tftypes.Value
+	if err := v.As(&elems); err != nil {
+		return nil, fmt.Errorf("unable to read statements: %w", err)
+	}
+
+	statements := make([]txStatement, len(elems))
+	for i, elem := range elems {
+		var attrs map[string]tftypes.Value
+		if err := elem.As(&attrs); err != nil {
+			return nil, fmt.Errorf("unable to read statements[%d]: %w", i, err)
+		}
+
+		if v, ok := attrs["apply"]; ok && !v.IsNull() {
+			if err := v.As(&statements[i].Apply); err != nil {
+				return nil, fmt.Errorf("unable to read statements[%d].apply: %w", i, err)
+			}
+		}
+		if v, ok := attrs["destroy"]; ok && !v.IsNull() {
+			if err := v.As(&statements[i].Destroy); err != nil {
+				return nil, fmt.Errorf("unable to read statements[%d].destroy: %w", i, err)
+			}
+		}
+	}
+
+	return statements, nil
+}
+
+// statementsAndIsolation decodes the statements and isolation attributes out
+// of a resource config/state map, as used by both Create/Update and
+// Read/Delete.
+func statementsAndIsolation(config map[string]tftypes.Value) ([]txStatement, txIsolation, error) {
+	statements, err := decodeStatements(config["statements"])
+	if err != nil {
+		return nil, "", err
+	}
+
+	var isolation string
+	if v, ok := config["isolation"]; ok && !v.IsNull() {
+		if err := v.As(&isolation); err != nil {
+			return nil, "", fmt.Errorf("unable to read isolation: %w", err)
+		}
+	}
+
+	return statements, txIsolation(isolation), nil
+}
+
+// diffStatements splits old and new statement lists into the old statements
+// that need destroying and the new statements that need applying, so Update
+// only touches what actually changed rather than re-running everything.
+// Statements are compared up to their first difference - a change (or
+// removal) partway through the list destroys everything from that point in
+// old (in reverse) and (re-)applies everything from that point in new, since
+// a later statement may depend on an earlier one changing.
+func diffStatements(old []txStatement, new []txStatement) (toDestroy []txStatement, toApply []txStatement) {
+	common := 0
+	for common < len(old) && common < len(new) && old[common] == new[common] {
+		common++
+	}
+	return old[common:], new[common:]
+}
+
+// statementsID derives a stable id for the id attribute from the statements
+// list, so Terraform has something to key the resource on. There's no
+// natural version/serial number for an arbitrary list of statements, so this
+// hashes them instead.
+func statementsID(statements []txStatement) string {
+	h := sha256.New()
+	for _, s := range statements {
+		h.Write([]byte(s.Apply))
+		h.Write([]byte{0})
+		h.Write([]byte(s.Destroy))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// withID returns config with its id attribute set to the given value,
+// leaving every other attribute untouched.
+func withID(config map[string]tftypes.Value, id string) map[string]tftypes.Value {
+	state := make(map[string]tftypes.Value, len(config))
+	for k, v := range config {
+		state[k] = v
+	}
+	state["id"] = tftypes.NewValue(tftypes.String, id)
+	return state
+}
+
+func (r *resourceTransaction) Create(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	statements, isolation, err := statementsAndIsolation(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := r.applyStatements(ctx, isolation, statements, false, 0); err != nil {
+		return nil, nil, fmt.Errorf("unable to apply statements: %w", err)
+	}
+
+	return withID(config, statementsID(statements)), nil, nil
+}
+
+// Read re-runs the read query, if one is set, to detect whether the
+// statements this resource applied are still in effect. A failure there
+// (e.g. the table they created is gone) surfaces as an error; Read does not
+// attempt to diff the query's result against the statements list, since that
+// would require a schema for an arbitrary, user-supplied query.
+func (r *resourceTransaction) Read(ctx context.Context, state map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	var readQuery string
+	if v, ok := state["read"]; ok && !v.IsNull() {
+		if err := v.As(&readQuery); err != nil {
+			return nil, nil, fmt.Errorf("unable to read read query: %w", err)
+		}
+	}
+	if readQuery == "" {
+		return state, nil, nil
+	}
+
+	queryer := retryingQueryer{dbQueryer: r.DB, policy: r.Policy}
+	rows, err := queryer.QueryContext(ctx, readQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to run read query: %w", err)
+	}
+	return state, nil, rows.Close()
+}
+
+func (r *resourceTransaction) Update(ctx context.Context, config map[string]tftypes.Value, state map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	newStatements, isolation, err := statementsAndIsolation(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldStatements, _, err := statementsAndIsolation(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toDestroy, toApply := diffStatements(oldStatements, newStatements)
+
+	if len(toDestroy) > 0 {
+		if err := r.applyStatements(ctx, isolation, toDestroy, true, len(oldStatements)-len(toDestroy)); err != nil {
+			return nil, nil, fmt.Errorf("unable to destroy removed/changed statements: %w", err)
+		}
+	}
+
+	if len(toApply) > 0 {
+		if err := r.applyStatements(ctx, isolation, toApply, false, len(newStatements)-len(toApply)); err != nil {
+			return nil, nil, fmt.Errorf("unable to apply new/changed statements: %w", err)
+		}
+	}
+
+	return withID(config, statementsID(newStatements)), nil, nil
+}
+
+func (r *resourceTransaction) Delete(ctx context.Context, state map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	statements, isolation, err := statementsAndIsolation(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.applyStatements(ctx, isolation, statements, true, 0); err != nil {
+		return nil, fmt.Errorf("unable to destroy statements: %w", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+)
+
+const retryMinInterval = 50 * time.Millisecond
+
+// retryPolicy controls how transient errors from QueryContext/ExecContext are
+// retried. It is populated from the provider's max_retries and
+// retry_max_interval attributes.
+type retryPolicy struct {
+	MaxRetries  int
+	MaxInterval time.Duration
+}
+
+// Do runs fn, retrying with exponential backoff and jitter (50ms up to
+// MaxInterval) when fn returns a transient error, per isRetryableError. It
+// gives up immediately on ctx cancellation or any non-retryable error.
+func (r retryPolicy) Do(ctx context.Context, fn func() error) error {
+	interval := retryMinInterval
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= r.MaxRetries || !isRetryableError(err) {
+			return err
+		}
+
+		sleep := interval/2 + time.Duration(rand.Int63n(int64(interval/2+1)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+
+		if interval *= 2; interval > r.MaxInterval {
+			interval = r.MaxInterval
+		}
+	}
+}
+
+// isRetryableError reports whether err represents a transient database error
+// worth retrying: a Postgres serialization failure (40001) or deadlock
+// (40P01), a MySQL deadlock (1213) or lock-wait timeout (1205), or a SQL
+// Server deadlock (1205).
+func isRetryableError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", "40P01":
+			return true
+		}
+		return false
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1213, 1205:
+			return true
+		}
+		return false
+	}
+
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		return msErr.Number == 1205
+	}
+
+	return false
+}
+
+// retryingQueryer wraps a dbQueryer so QueryContext transparently retries
+// transient errors. sql_transaction's Read wraps p.DB in this before running
+// its read query.
+type retryingQueryer struct {
+	dbQueryer
+	policy retryPolicy
+}
+
+func (q retryingQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := q.policy.Do(ctx, func() error {
+		var err error
+		rows, err = q.dbQueryer.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// retryingExecer wraps a dbExecer so ExecContext transparently retries
+// transient errors. It's only a fit for a single, non-transactional
+// ExecContext: a transient error inside an open transaction aborts the whole
+// transaction, so retrying the same statement against the same tx can't
+// recover it. sql_transaction's applyStatements retries its whole
+// begin/exec/commit attempt instead, for that reason.
+type retryingExecer struct {
+	dbExecer
+	policy retryPolicy
+}
+
+func (e retryingExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := e.policy.Do(ctx, func() error {
+		var err error
+		result, err = e.dbExecer.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}